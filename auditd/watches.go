@@ -0,0 +1,192 @@
+package auditd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"regexp"
+
+	"golang.org/x/sys/unix"
+)
+
+// auditListRules is AUDIT_LIST_RULES from linux/audit.h: asking for it
+// makes the kernel dump every loaded rule as a series of audit_rule_data
+// records, same as `auditctl -l`.
+const auditListRules = 1013
+
+// Field counts from linux/audit.h's struct audit_rule_data.
+const (
+	auditMaxFields   = 64
+	auditBitmaskSize = 64
+)
+
+// Rule field identifiers we care about, from linux/audit.h. AUDIT_WATCH and
+// AUDIT_DIR are string fields (path, directory); AUDIT_PERM's value is a
+// permission bitmask rather than a string.
+const (
+	auditWatch = 105
+	auditPerm  = 106
+	auditDir   = 107
+)
+
+// AUDIT_PERM_* bits from linux/audit.h, matching auditctl -p's r/w/x/a.
+const (
+	auditPermExec  = 1
+	auditPermWrite = 2
+	auditPermRead  = 4
+	auditPermAttr  = 8
+)
+
+// defaultRulesFile is the typical location auditd persists its ruleset to
+// disk, used as a fallback source when the netlink ruleset can't be read.
+const defaultRulesFile = "/etc/audit/audit.rules"
+
+// Watch is a single filesystem watch rule, from whichever source produced it.
+type Watch struct {
+	Path string
+	Perm string
+}
+
+// ListWatches returns the AUDIT_WATCH/AUDIT_DIR rules auditd currently has
+// loaded. It asks the kernel directly over NETLINK_AUDIT first, since that
+// reflects rules added at runtime via `auditctl -w` that were never written
+// back to disk; if that fails (most commonly EPERM for a caller without
+// CAP_AUDIT_READ), it falls back to parsing auditd's on-disk rules file.
+func ListWatches() ([]Watch, error) {
+	watches, err := listWatchesNetlink()
+	if err == nil {
+		return watches, nil
+	}
+	watches, ferr := parseRulesFile(defaultRulesFile)
+	if ferr != nil {
+		return nil, fmt.Errorf("auditd: netlink unavailable (%v), and fallback failed: %v", err, ferr)
+	}
+	return watches, nil
+}
+
+// listWatchesNetlink asks the kernel's audit subsystem for its current
+// ruleset over NETLINK_AUDIT (AUDIT_LIST_RULES) and returns the
+// AUDIT_WATCH/AUDIT_DIR rules it finds.
+func listWatchesNetlink() ([]Watch, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW, netlinkAudit)
+	if err != nil {
+		return nil, fmt.Errorf("socket: %v", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("bind: %v", err)
+	}
+	header := nlHeader(auditListRules, unix.NLM_F_REQUEST|unix.NLM_F_DUMP, 0)
+	parts, err := nlRequest(fd, header, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list rules: %v", err)
+	}
+	var out []Watch
+	for _, p := range parts {
+		if w, ok := parseAuditRule(p); ok {
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+
+// parseAuditRule decodes one struct audit_rule_data record and extracts its
+// AUDIT_WATCH/AUDIT_DIR path and AUDIT_PERM bits. Rules that carry no watch
+// field (plain syscall/arch filters, etc.) are reported as not-ok. This
+// assumes a watch rule's only string fields are the watch path and an
+// optional filterkey; it doesn't track the length of every string field
+// linux/audit.h defines, just the ones postex cares about.
+func parseAuditRule(b []byte) (Watch, bool) {
+	const fixedLen = 4 + 4 + 4 + 4*auditBitmaskSize // flags, action, field_count, mask
+	if len(b) < fixedLen+4 {
+		return Watch{}, false
+	}
+	fieldCount := int(binary.LittleEndian.Uint32(b[8:12]))
+	if fieldCount > auditMaxFields {
+		fieldCount = auditMaxFields
+	}
+	fieldsOff := fixedLen
+	valuesOff := fieldsOff + 4*auditMaxFields
+	fieldflagsOff := valuesOff + 4*auditMaxFields
+	buflenOff := fieldflagsOff + 4*auditMaxFields
+	if len(b) < buflenOff+4 {
+		return Watch{}, false
+	}
+	buflen := int(binary.LittleEndian.Uint32(b[buflenOff : buflenOff+4]))
+	bufOff := buflenOff + 4
+	if len(b) < bufOff+buflen {
+		return Watch{}, false
+	}
+	buf := b[bufOff : bufOff+buflen]
+
+	var path string
+	var permBits uint32
+	strPos := 0
+	for i := 0; i < fieldCount; i++ {
+		field := binary.LittleEndian.Uint32(b[fieldsOff+4*i : fieldsOff+4*i+4])
+		value := binary.LittleEndian.Uint32(b[valuesOff+4*i : valuesOff+4*i+4])
+		switch field {
+		case auditWatch, auditDir:
+			n := int(value)
+			if strPos+n <= len(buf) {
+				path = string(buf[strPos : strPos+n])
+			}
+			strPos += n
+		case auditPerm:
+			permBits = value
+		}
+	}
+	if path == "" {
+		return Watch{}, false
+	}
+	return Watch{Path: path, Perm: permString(permBits)}, true
+}
+
+// permString renders AUDIT_PERM bits the way `auditctl -p` would print
+// them: some combination of r, w, x, a.
+func permString(bits uint32) string {
+	s := ""
+	if bits&auditPermRead != 0 {
+		s += "r"
+	}
+	if bits&auditPermWrite != 0 {
+		s += "w"
+	}
+	if bits&auditPermExec != 0 {
+		s += "x"
+	}
+	if bits&auditPermAttr != 0 {
+		s += "a"
+	}
+	return s
+}
+
+// rulesFileWatch matches a `-w <path> ... -p <perm>` rule line the way
+// auditd's rules file expresses a watch.
+var rulesFileWatch = regexp.MustCompile(`-w ([^[:space:]]+).* -p ([[:alpha:]]+)`)
+
+// parseRulesFile parses watch rules out of auditd's on-disk rules file,
+// the format `auditctl -w` rules are persisted in. It's a coarser source
+// than the live netlink ruleset (it misses anything loaded at runtime and
+// not saved back to disk), but it needs no special capability to read.
+func parseRulesFile(path string) ([]Watch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %v: %v", path, err)
+	}
+	defer f.Close()
+
+	var found []Watch
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		matches := rulesFileWatch.FindStringSubmatch(scanner.Text())
+		if len(matches) == 3 {
+			found = append(found, Watch{Path: matches[1], Perm: matches[2]})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %v: %v", path, err)
+	}
+	return found, nil
+}
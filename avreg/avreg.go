@@ -0,0 +1,295 @@
+// Package avreg implements a signature-driven registry of antivirus/EDR
+// systems postex can detect. Each detectable system is a data-only
+// Signature (paths, process names, kernel modules, systemd units, ports,
+// config files) rather than a hand-written Go type, so adding a new vendor
+// is an edit to signatures.yaml instead of a code change. Callers can also
+// load an additional signature pack at runtime via LoadFile.
+package avreg
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+//go:embed signatures.yaml
+var embeddedFS embed.FS
+
+// Signature is the on-disk (YAML or JSON) description of one AV/EDR system.
+type Signature struct {
+	Name          string   `yaml:"name" json:"name"`
+	Paths         []string `yaml:"paths" json:"paths"`
+	Procs         []string `yaml:"procs" json:"procs"`
+	KernelModules []string `yaml:"kernel_modules" json:"kernel_modules"`
+	SystemdUnits  []string `yaml:"systemd_units" json:"systemd_units"`
+	Ports         []int    `yaml:"ports" json:"ports"`
+	ConfigFiles   []string `yaml:"config_files" json:"config_files"`
+	// ContentRegexes, if set, restrict ConfigFiles() to files whose content
+	// matches at least one of these patterns.
+	ContentRegexes []string `yaml:"content_regexes" json:"content_regexes"`
+}
+
+// Process is a minimal, comparable process reference. It mirrors
+// procmon.Process so avreg doesn't need to depend on postex's process
+// monitoring to describe what it found.
+type Process struct {
+	Pid  int
+	Name string
+}
+
+// KernelModule is a minimal, comparable loaded kernel module reference.
+type KernelModule struct {
+	Name string
+	Size int
+}
+
+// ProcLister returns the processes currently running, so a Detector can
+// check its Signature's Procs against them without avreg depending on
+// postex's process monitor directly.
+type ProcLister func() []Process
+
+// Discoverer is implemented by every detectable AV/EDR system.
+type Discoverer interface {
+	// Name of the AV/EDR system.
+	Name() string
+	// Paths returns the signature's filesystem paths that exist.
+	Paths() []string
+	// Procs returns the signature's process names that are currently running.
+	Procs() []Process
+	// KernelModules returns the signature's kernel module names that are loaded.
+	KernelModules() []KernelModule
+	// Ports returns the signature's ports that are currently listening.
+	Ports() []int
+	// SystemdUnits returns the signature's unit names that have a unit file installed.
+	SystemdUnits() []string
+	// ConfigFiles returns the signature's config file paths that exist and,
+	// if ContentRegexes is set, whose content matches one of them.
+	ConfigFiles() []string
+}
+
+// Detector is the generic Discoverer every Signature is turned into.
+type Detector struct {
+	sig   Signature
+	procs ProcLister
+}
+
+// NewDetector returns a Discoverer for sig, using procs to answer Procs().
+func NewDetector(sig Signature, procs ProcLister) *Detector {
+	return &Detector{sig: sig, procs: procs}
+}
+
+func (d *Detector) Name() string { return d.sig.Name }
+
+func (d *Detector) Paths() []string { return existingPaths(d.sig.Paths) }
+
+func (d *Detector) Procs() []Process {
+	found := []Process{}
+	if len(d.sig.Procs) == 0 || d.procs == nil {
+		return found
+	}
+	for _, p := range d.procs() {
+		for _, need := range d.sig.Procs {
+			if need == p.Name {
+				found = append(found, p)
+			}
+		}
+	}
+	return found
+}
+
+func (d *Detector) KernelModules() []KernelModule {
+	return matchModules(d.sig.KernelModules)
+}
+
+func (d *Detector) Ports() []int {
+	return matchPorts(d.sig.Ports)
+}
+
+func (d *Detector) SystemdUnits() []string {
+	return matchUnits(d.sig.SystemdUnits)
+}
+
+func (d *Detector) ConfigFiles() []string {
+	files := existingPaths(d.sig.ConfigFiles)
+	if len(d.sig.ContentRegexes) == 0 {
+		return files
+	}
+	matched := []string{}
+	for _, f := range files {
+		if contentMatches(f, d.sig.ContentRegexes) {
+			matched = append(matched, f)
+		}
+	}
+	return matched
+}
+
+// Load returns the built-in signature pack embedded in the binary.
+func Load() ([]Signature, error) {
+	b, err := embeddedFS.ReadFile("signatures.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("avreg: reading embedded signatures: %v", err)
+	}
+	return parse(b, "signatures.yaml")
+}
+
+// LoadFile reads and parses a user-supplied signature pack for
+// --av-signatures. The format (YAML or JSON) is chosen by file extension,
+// defaulting to YAML.
+func LoadFile(path string) ([]Signature, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("avreg: reading %s: %v", path, err)
+	}
+	return parse(b, path)
+}
+
+func parse(b []byte, path string) ([]Signature, error) {
+	var sigs []Signature
+	var err error
+	if strings.HasSuffix(path, ".json") {
+		err = json.Unmarshal(b, &sigs)
+	} else {
+		err = yaml.Unmarshal(b, &sigs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("avreg: parsing %s: %v", path, err)
+	}
+	return sigs, nil
+}
+
+// Detectors builds a Discoverer for each Signature, all sharing procs as
+// their process lister.
+func Detectors(sigs []Signature, procs ProcLister) []Discoverer {
+	out := make([]Discoverer, 0, len(sigs))
+	for _, s := range sigs {
+		out = append(out, NewDetector(s, procs))
+	}
+	return out
+}
+
+// existingPaths returns the subset of paths that exist on the filesystem.
+func existingPaths(paths []string) []string {
+	found := []string{}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		}
+	}
+	return found
+}
+
+// matchModules returns the subset of names currently loaded, per /proc/modules.
+func matchModules(names []string) []KernelModule {
+	found := []KernelModule{}
+	if len(names) == 0 {
+		return found
+	}
+	b, err := ioutil.ReadFile("/proc/modules")
+	if err != nil {
+		return found
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, need := range names {
+			if fields[0] == need {
+				size, _ := strconv.Atoi(fields[1])
+				found = append(found, KernelModule{Name: fields[0], Size: size})
+			}
+		}
+	}
+	return found
+}
+
+// systemdUnitDirs are the locations systemd loads unit files from, checked
+// in the order systemd itself prefers (local admin overrides first).
+var systemdUnitDirs = []string{
+	"/etc/systemd/system",
+	"/run/systemd/system",
+	"/lib/systemd/system",
+	"/usr/lib/systemd/system",
+}
+
+// matchUnits returns the subset of unit names that have an installed unit file.
+func matchUnits(names []string) []string {
+	found := []string{}
+	for _, name := range names {
+		for _, dir := range systemdUnitDirs {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				found = append(found, name)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// matchPorts returns the subset of ports currently in LISTEN state,
+// per /proc/net/{tcp,tcp6}.
+func matchPorts(ports []int) []int {
+	found := []int{}
+	if len(ports) == 0 {
+		return found
+	}
+	listening := map[int]bool{}
+	for _, p := range append(listeningPorts("/proc/net/tcp"), listeningPorts("/proc/net/tcp6")...) {
+		listening[p] = true
+	}
+	for _, p := range ports {
+		if listening[p] {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// tcpListen is the "st" field value /proc/net/tcp uses for TCP_LISTEN.
+const tcpListen = "0A"
+
+// listeningPorts parses a /proc/net/tcp-style file for ports in LISTEN state.
+func listeningPorts(path string) []int {
+	ports := []int{}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ports
+	}
+	lines := strings.Split(string(b), "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != tcpListen {
+			continue
+		}
+		parts := strings.Split(fields[1], ":")
+		if len(parts) != 2 {
+			continue
+		}
+		if port, err := strconv.ParseInt(parts[1], 16, 32); err == nil {
+			ports = append(ports, int(port))
+		}
+	}
+	return ports
+}
+
+// contentMatches reports whether path's content matches any of patterns.
+func contentMatches(path string, patterns []string) bool {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for _, pat := range patterns {
+		if re, err := regexp.Compile(pat); err == nil && re.Match(b) {
+			return true
+		}
+	}
+	return false
+}
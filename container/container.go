@@ -0,0 +1,224 @@
+// Package container fingerprints which container or sandbox runtime, if
+// any, the current process is running under. It replaces a bare yes/no
+// guess (process count plus a "docker" substring search) with a typed
+// Runtime and the evidence that identified it, covering Docker, Podman,
+// containerd, LXC, systemd-nspawn, Kubernetes, gVisor, Kata, Firejail,
+// Bubblewrap, and WSL2, across both cgroup v1 and v2 layouts.
+package container
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// Runtime identifies a container or sandbox technology.
+type Runtime string
+
+const (
+	None       Runtime = "none"
+	Docker     Runtime = "docker"
+	Podman     Runtime = "podman"
+	Containerd Runtime = "containerd"
+	LXC        Runtime = "lxc"
+	Nspawn     Runtime = "nspawn"
+	Kubernetes Runtime = "k8s"
+	GVisor     Runtime = "gvisor"
+	Kata       Runtime = "kata"
+	Firejail   Runtime = "firejail"
+	Bubblewrap Runtime = "bwrap"
+	WSL        Runtime = "wsl"
+)
+
+// Detection is the result of Detect: the Runtime postex believes it's
+// running under, and the specific evidence that led to that conclusion so
+// callers can show their work rather than a bare bool.
+type Detection struct {
+	Runtime  Runtime
+	Evidence []string
+}
+
+// Detect fingerprints the current process's container/sandbox runtime. It
+// checks cheapest/most definitive signals first (marker files) and falls
+// back to cgroup, mountinfo, and environ parsing.
+func Detect() Detection {
+	if exists("/.dockerenv") {
+		return Detection{Docker, []string{"/.dockerenv exists"}}
+	}
+	if exists("/run/.containerenv") {
+		return Detection{Podman, []string{"/run/.containerenv exists"}}
+	}
+	if d, ok := detectGVisor(); ok {
+		return d
+	}
+	if d, ok := detectWSL(); ok {
+		return d
+	}
+	if d, ok := detectCgroup(); ok {
+		return d
+	}
+	if d, ok := detectEnviron(); ok {
+		return d
+	}
+	if d, ok := detectMountinfo(); ok {
+		return d
+	}
+	if d, ok := detectCmdline(); ok {
+		return d
+	}
+	return Detection{None, nil}
+}
+
+func exists(path string) bool {
+	_, err := ioutil.ReadFile(path)
+	return err == nil
+}
+
+func readFile(path string) (string, bool) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// detectGVisor looks for gVisor's distinctive fake kernel version string,
+// which embeds "gVisor" where a real kernel would have a build string.
+func detectGVisor() (Detection, bool) {
+	version, ok := readFile("/proc/version")
+	if !ok {
+		return Detection{}, false
+	}
+	if strings.Contains(version, "gVisor") {
+		return Detection{GVisor, []string{"/proc/version contains \"gVisor\""}}, true
+	}
+	return Detection{}, false
+}
+
+// detectWSL looks for Microsoft's WSL marker in the kernel version string.
+func detectWSL() (Detection, bool) {
+	version, ok := readFile("/proc/version")
+	if !ok {
+		return Detection{}, false
+	}
+	lower := strings.ToLower(version)
+	if strings.Contains(lower, "microsoft") || strings.Contains(lower, "wsl") {
+		return Detection{WSL, []string{"/proc/version contains a Microsoft/WSL marker"}}, true
+	}
+	return Detection{}, false
+}
+
+// detectCgroup parses /proc/1/cgroup, handling both the v1 layout
+// ("<hierarchy-id>:<controllers>:<path>" per line) and the v2 unified
+// layout ("0::<path>").
+func detectCgroup() (Detection, bool) {
+	data, ok := readFile("/proc/1/cgroup")
+	if !ok {
+		return Detection{}, false
+	}
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		path := parts[2]
+		if path == "/" || path == "" {
+			continue
+		}
+		switch {
+		case strings.Contains(path, "kubepods"):
+			return Detection{Kubernetes, []string{"cgroup path contains \"kubepods\": " + path}}, true
+		case strings.Contains(path, "docker"):
+			return Detection{Docker, []string{"cgroup path contains \"docker\": " + path}}, true
+		case strings.Contains(path, "containerd"):
+			return Detection{Containerd, []string{"cgroup path contains \"containerd\": " + path}}, true
+		case strings.Contains(path, "libpod") || strings.Contains(path, "podman"):
+			return Detection{Podman, []string{"cgroup path contains a podman/libpod marker: " + path}}, true
+		case strings.Contains(path, "lxc"):
+			return Detection{LXC, []string{"cgroup path contains \"lxc\": " + path}}, true
+		case strings.Contains(path, "machine.slice") || strings.Contains(path, "machine-"):
+			return Detection{Nspawn, []string{"cgroup path looks like a systemd-nspawn machine scope: " + path}}, true
+		case strings.Contains(path, "firejail"):
+			return Detection{Firejail, []string{"cgroup path contains \"firejail\": " + path}}, true
+		}
+		// A non-root cgroup path with no recognized marker isn't
+		// necessarily a container: plain systemd hosts put PID 1 in
+		// /init.scope under cgroup v2. Keep looking rather than guessing.
+	}
+	return Detection{}, false
+}
+
+// detectEnviron reads PID 1's environment for markers that cgroup parsing
+// can miss: systemd-nspawn/LXC set "container=<name>", and Kubernetes
+// injects KUBERNETES_SERVICE_HOST into every pod.
+func detectEnviron() (Detection, bool) {
+	data, ok := readFile("/proc/1/environ")
+	if !ok {
+		return Detection{}, false
+	}
+	vars := strings.Split(data, "\x00")
+	for _, v := range vars {
+		switch {
+		case strings.HasPrefix(v, "KUBERNETES_SERVICE_HOST="):
+			return Detection{Kubernetes, []string{"/proc/1/environ has " + v}}, true
+		case strings.HasPrefix(v, "container=lxc"):
+			return Detection{LXC, []string{"/proc/1/environ has " + v}}, true
+		case strings.HasPrefix(v, "container=systemd-nspawn"):
+			return Detection{Nspawn, []string{"/proc/1/environ has " + v}}, true
+		case strings.HasPrefix(v, "container=podman"):
+			return Detection{Podman, []string{"/proc/1/environ has " + v}}, true
+		case strings.HasPrefix(v, "container=docker"):
+			return Detection{Docker, []string{"/proc/1/environ has " + v}}, true
+		case strings.HasPrefix(v, "container="):
+			return Detection{Docker, []string{"/proc/1/environ has a generic " + v + " marker"}}, true
+		}
+	}
+	return Detection{}, false
+}
+
+// detectMountinfo looks at /proc/self/mountinfo for overlayfs (used by most
+// container runtimes for the root filesystem) and for bind mounts whose
+// source path gives away the runtime, e.g. Kata's virtio-fs share or
+// Bubblewrap's "/newroot" staging mount.
+func detectMountinfo() (Detection, bool) {
+	data, ok := readFile("/proc/self/mountinfo")
+	if !ok {
+		return Detection{}, false
+	}
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		mountPoint := fields[4]
+		switch {
+		case mountPoint == "/" && strings.Contains(line, " overlay "):
+			return Detection{Docker, []string{"root filesystem is overlayfs: " + line}}, true
+		case strings.Contains(line, "kata"):
+			return Detection{Kata, []string{"mountinfo references kata: " + line}}, true
+		case strings.HasPrefix(mountPoint, "/newroot"):
+			return Detection{Bubblewrap, []string{"mountinfo has a /newroot staging mount: " + line}}, true
+		}
+	}
+	return Detection{}, false
+}
+
+// detectCmdline checks PID 1's argv for the executable that put us here,
+// catching sandboxes like Bubblewrap and Firejail that don't always leave
+// cgroup/environ markers depending on how they were invoked.
+func detectCmdline() (Detection, bool) {
+	data, ok := readFile("/proc/1/cmdline")
+	if !ok {
+		return Detection{}, false
+	}
+	args := strings.Split(data, "\x00")
+	if len(args) == 0 {
+		return Detection{}, false
+	}
+	switch {
+	case strings.Contains(args[0], "bwrap"):
+		return Detection{Bubblewrap, []string{"/proc/1/cmdline is " + args[0]}}, true
+	case strings.Contains(args[0], "firejail"):
+		return Detection{Firejail, []string{"/proc/1/cmdline is " + args[0]}}, true
+	}
+	return Detection{}, false
+}
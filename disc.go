@@ -5,16 +5,21 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
 	utmp "github.com/EricLagergren/go-gnulib/utmp"
-	netstat "github.com/drael/GOnetstat"
-	ps "github.com/unixist/go-ps"
+	"github.com/unixist/postex/auditd"
+	"github.com/unixist/postex/avreg"
+	"github.com/unixist/postex/container"
+	"github.com/unixist/postex/poll"
+	"github.com/unixist/postex/procmon"
+	"github.com/unixist/postex/reporter"
+	"github.com/unixist/postex/sandbox"
 	netlink "github.com/vishvananda/netlink"
 )
 
@@ -26,31 +31,71 @@ var (
 	flag_pkey_dirs  = flag.String("flag_pkey_dirs", "/root,/home", "Comma-separated directories to search for private keys. Default is '/root,/home'. Requires --pkeys.")
 	flag_pkey_sleep = flag.Int("flag_pkey_sleep", 0, "Length of time in milliseconds to sleep between examining files. Requires --flag_pkey_dirs.")
 	flag_av         = flag.Bool("av", false, "Check for signs of A/V services running or present.")
+	flag_av_sigs    = flag.String("av-signatures", "", "Path to an additional AV/EDR signature pack (YAML or JSON) to load alongside the built-in ones.")
 	flag_container  = flag.Bool("container", false, "Detect if this system is running in a container.")
 	flag_net        = flag.Bool("net", false, "Grab IPv4 and IPv6 networking connections.")
 	flag_watches    = flag.Bool("watches", false, "Grab which files/directories are being watched for modification/access/execution.")
 	flag_arp        = flag.Bool("arp", false, "Grab ARP table for all devices.")
 	flag_who        = flag.Bool("who", false, "List who's logged in and from where.")
 	// Recon over time
-	flag_poll_net   = flag.Bool("pollnet", false, "Long poll for networking connections and a) output a summary; or b) output regular connection status. [NOT IMPLEMENTED]")
-	flag_poll_users = flag.Bool("pollusers", false, "Long poll for users that log into the system. [NOT IMPLEMENTED]")
+	flag_poll_net     = flag.Bool("pollnet", false, "Long poll for networking connections, emitting connection_opened/connection_closed events as they happen.")
+	flag_poll_users   = flag.Bool("pollusers", false, "Long poll for users that log into the system, emitting user_login/user_logout events as they happen.")
+	flag_audit_stream = flag.Bool("audit-stream", false, "Subscribe to the kernel's live audit record feed and emit raw records as they arrive. Requires CAP_AUDIT_READ.")
+	flag_poll_every   = flag.Duration("poll-interval", 2*time.Second, "How often to snapshot state while --pollnet or --pollusers is running.")
+	flag_poll_dedup   = flag.Duration("poll-dedup", 0, "Suppress repeat poll events for the same connection/session within this window. 0 disables dedup.")
+	flag_poll_summary = flag.Bool("poll-summary", false, "On SIGINT, print an aggregate summary (unique peers, top talkers, session durations) after the streamed events.")
 
 	// Non-recon
-	flag_stalk = flag.String("stalk", "", "Wait until a user logs in and then do something. Use \"*\" to match any user.")
+	flag_stalk      = flag.String("stalk", "", "Wait until a user logs in and then do something. Use \"*\" to match any user.")
+	flag_no_sandbox = flag.Bool("no-sandbox", false, "Skip installing the seccomp-bpf syscall filter for the active modes.")
+
+	// Output
+	flag_format = flag.String("format", "text", "Output format for findings: text, json, or ndjson.")
+	flag_output = flag.String("output", "", "Where to send findings: a file path, syslog://, or an http(s):// endpoint. Defaults to stdout.")
 )
 
 var (
-	// Antivirus systems we detect
-	AVSystems = []AVDiscoverer{
-		OSSECAV{name: "OSSEC"},
-		SophosAV{name: "Sophos"},
-	}
-	// The typical location where auditd looks for its ruleset
-	AuditdRules = "/etc/audit/audit.rules"
 	// The typical location utmp stores login information
 	UtmpPath = "/var/run/utmp"
 )
 
+var (
+	// procMon and sockMon are the shared, lazily-started netlink monitors
+	// backing process/AV lookups, --net, and --pollnet.
+	// They're package-level singletons because each opens a kernel socket
+	// and maintains live state that every caller should share, rather than
+	// re-scanning /proc or re-dumping sock_diag per call.
+	procMon *procmon.ProcessMonitor
+	sockMon *procmon.SocketMonitor
+)
+
+// procMonitor lazily starts and returns the shared ProcessMonitor.
+func procMonitor() *procmon.ProcessMonitor {
+	if procMon == nil {
+		mon, err := procmon.NewProcessMonitor()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "procmon:", err)
+			return nil
+		}
+		procMon = mon
+	}
+	return procMon
+}
+
+// sockMonitor lazily starts and returns the shared SocketMonitor, polling
+// at the interval given by --poll-interval.
+func sockMonitor() *procmon.SocketMonitor {
+	if sockMon == nil {
+		mon, err := procmon.NewSocketMonitor(*flag_poll_every)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "procmon:", err)
+			return nil
+		}
+		sockMon = mon
+	}
+	return sockMon
+}
+
 type stalkAction func(string) error
 
 type privateKey struct {
@@ -58,6 +103,14 @@ type privateKey struct {
 	encrypted bool
 }
 
+// Kind identifies a privateKey as a Finding.
+func (p privateKey) Kind() string { return "private_key" }
+
+// Fields exposes a privateKey's data for the reporter subsystem.
+func (p privateKey) Fields() map[string]interface{} {
+	return map[string]interface{}{"path": p.path, "encrypted": p.encrypted}
+}
+
 // watch holds the information for which the system is attempting to detect access.
 type watch struct {
 	// Path being watched.
@@ -66,6 +119,14 @@ type watch struct {
 	action string
 }
 
+// Kind identifies a watch as a Finding.
+func (w watch) Kind() string { return "watch" }
+
+// Fields exposes a watch's data for the reporter subsystem.
+func (w watch) Fields() map[string]interface{} {
+	return map[string]interface{}{"path": w.path, "action": w.action}
+}
+
 type who struct {
 	// Username, line (tty/pty), originating host that user is logging in from
 	user, line, host string
@@ -75,112 +136,144 @@ type who struct {
 	time int32
 }
 
-type process struct {
-	pid  int
-	name string
+// Kind identifies a who as a Finding.
+func (w who) Kind() string { return "who" }
+
+// Fields exposes a who's data for the reporter subsystem.
+func (w who) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"user": w.user, "line": w.line, "host": w.host, "pid": w.pid, "time": w.time,
+	}
 }
-type loadedKernelModule struct {
-	address string
-	size    int
-	name    string
+
+// avFinding reports a detected AV/EDR system and the artifacts that identified it.
+type avFinding struct {
+	name         string
+	paths        []string
+	procs        []avreg.Process
+	mods         []avreg.KernelModule
+	ports        []int
+	systemdUnits []string
+	configFiles  []string
 }
 
-type OSSECAV struct {
-	AVDiscoverer
-	name string
+// Kind identifies an avFinding as a Finding.
+func (a avFinding) Kind() string { return "av" }
+
+// Fields exposes an avFinding's data for the reporter subsystem.
+func (a avFinding) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"name": a.name, "paths": a.paths, "procs": a.procs, "modules": a.mods,
+		"ports": a.ports, "systemd_units": a.systemdUnits, "config_files": a.configFiles,
+	}
 }
 
-type SophosAV struct {
-	AVDiscoverer
-	name string
+// connectionFinding reports a single established network connection.
+type connectionFinding struct {
+	proto                  string
+	localIp, foreignIp     string
+	localPort, foreignPort uint16
 }
 
-// Each AV system implements this interface to expose artifacts of the detected system.
-type AVDiscoverer interface {
-	// Filesystem paths of binaries
-	Paths() []string
-	// Running processes
-	Procs() []process
-	// Loaded kernel modules
-	KernelModules() []loadedKernelModule
-	// Name of the AV system
-	Name() string
+// Kind identifies a connectionFinding as a Finding.
+func (c connectionFinding) Kind() string { return "connection" }
+
+// Fields exposes a connectionFinding's data for the reporter subsystem.
+func (c connectionFinding) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"proto": c.proto, "local_ip": c.localIp, "local_port": c.localPort,
+		"foreign_ip": c.foreignIp, "foreign_port": c.foreignPort,
+	}
 }
 
-func (o OSSECAV) Paths() []string {
-	return existingPaths([]string{
-		"/var/ossec",
-	})
+// arpFinding reports a single ARP table entry.
+type arpFinding struct {
+	mac, ip string
 }
 
-func (o OSSECAV) Procs() []process {
-	return runningProcs([]string{
-		"ossec-agentd",
-		"ossec-syscheckd",
-	})
+// Kind identifies an arpFinding as a Finding.
+func (a arpFinding) Kind() string { return "arp" }
+
+// Fields exposes an arpFinding's data for the reporter subsystem.
+func (a arpFinding) Fields() map[string]interface{} {
+	return map[string]interface{}{"mac": a.mac, "ip": a.ip}
 }
 
-// KernelModules returns an empty list as OSSEC doesn't use kernel modules.
-func (o OSSECAV) KernelModules() []loadedKernelModule {
-	return []loadedKernelModule{}
+// pollEventFinding adapts a poll.Event to the reporter.Finding interface.
+type pollEventFinding struct {
+	poll.Event
 }
 
-func (o OSSECAV) Name() string {
-	return o.name
+// Kind identifies a pollEventFinding as a Finding.
+func (p pollEventFinding) Kind() string { return string(p.Event.Kind) }
+
+// Fields exposes a pollEventFinding's data for the reporter subsystem.
+func (p pollEventFinding) Fields() map[string]interface{} {
+	switch p.Event.Kind {
+	case poll.ConnectionOpened, poll.ConnectionClosed:
+		c := p.Event.Connection
+		return map[string]interface{}{
+			"proto": c.Proto, "local_ip": c.LocalIp, "local_port": c.LocalPort,
+			"foreign_ip": c.ForeignIp, "foreign_port": c.ForeignPort, "at": p.Event.At,
+		}
+	default:
+		s := p.Event.Session
+		return map[string]interface{}{
+			"user": s.User, "host": s.Host, "line": s.Line, "pid": s.Pid, "login_time": s.Time, "at": p.Event.At,
+		}
+	}
 }
 
-func (s SophosAV) Paths() []string {
-	return existingPaths([]string{
-		"/etc/init.d/sav-protect",
-		"/etc/init.d/sav-rms",
-		"/lib/systemd/system/sav-protect.service",
-		"/lib/systemd/system/sav-rms.service",
-		"/opt/sophos-av",
-	})
+// pollSummaryFinding reports the aggregate counts accumulated by a Poller, printed at exit.
+type pollSummaryFinding struct {
+	poll.Summary
 }
 
-func (s SophosAV) Procs() []process {
-	return runningProcs([]string{
-		"savd",
-		"savscand",
-	})
+// Kind identifies a pollSummaryFinding as a Finding.
+func (p pollSummaryFinding) Kind() string { return "poll_summary" }
+
+// Fields exposes a pollSummaryFinding's data for the reporter subsystem.
+func (p pollSummaryFinding) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"connections_opened": p.ConnectionsOpened,
+		"connections_closed": p.ConnectionsClosed,
+		"logins":             p.Logins,
+		"logouts":            p.Logouts,
+		"unique_peers":       p.UniquePeers,
+		"top_talkers":        p.TopTalkers(5),
+		"session_durations":  p.SessionDurations,
+	}
 }
 
-func (o SophosAV) KernelModules() []loadedKernelModule {
-	return []loadedKernelModule{}
+// auditEventFinding adapts an auditd.Event to the reporter.Finding interface.
+type auditEventFinding struct {
+	auditd.Event
 }
 
-func (o SophosAV) Name() string {
-	return o.name
+// Kind identifies an auditEventFinding as a Finding.
+func (a auditEventFinding) Kind() string { return "audit_event" }
+
+// Fields exposes an auditEventFinding's data for the reporter subsystem.
+func (a auditEventFinding) Fields() map[string]interface{} {
+	return map[string]interface{}{"type": a.Event.Type, "text": a.Event.Text}
 }
 
-// existingPaths returns a subset of paths that exist on the filesystem.
-func existingPaths(paths []string) []string {
-	found := []string{}
-	for _, path := range paths {
-		if _, err := os.Stat(path); err == nil {
-			found = append(found, path)
-		}
-	}
-	return found
+// containerFinding reports which container/sandbox runtime, if any, postex
+// believes it's running under, along with the evidence that identified it.
+type containerFinding struct {
+	detection container.Detection
 }
 
-// runningProcs returns a subset of processes that are currently running.
-func runningProcs(procs []string) []process {
-	allProcs, _ := ps.Processes()
-	found := []process{}
-	for _, aproc := range allProcs {
-		procName := aproc.Executable()
-		for _, need := range procs {
-			if need == procName {
-				found = append(found, process{
-					pid:  aproc.Pid(),
-					name: procName,
-				})
-			}
-		}
+// Kind identifies a containerFinding as a Finding.
+func (c containerFinding) Kind() string { return "container" }
+
+// Fields exposes a containerFinding's data for the reporter subsystem.
+func (c containerFinding) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"is_container": c.detection.Runtime != container.None,
+		"runtime":      string(c.detection.Runtime),
+		"evidence":     c.detection.Evidence,
 	}
-	return found
 }
 
 // getPrivateKey extracts a privateKey object from a string if a key exists.
@@ -233,33 +326,6 @@ func getSSHKeys(dir string, sleep int) []privateKey {
 	return pkeys
 }
 
-// isContainer looks at init's cgroup and total process count to guess at
-// whether we're in a container
-func isContainer() bool {
-	procs, err := ps.Processes()
-	if err != nil {
-		return false
-	}
-	if len(procs) <= 10 {
-		return true
-	}
-	t, err := ioutil.ReadFile("/proc/1/cgroup")
-	if err != nil {
-		return false
-	}
-	for _, line := range strings.Split(string(t), "\n") {
-		if line == "" {
-			break
-		}
-		if strings.Index(line, "docker") != -1 {
-			return true
-		} else if !strings.HasSuffix(line, ":/") {
-			return true
-		}
-	}
-	return false
-}
-
 // getArp fetches the current arp table, the map between known MACs and their IPs
 func getArp() []netlink.Neigh {
 	neighs, err := netlink.NeighList(0, 0)
@@ -289,35 +355,131 @@ func getWho() []who {
 	return found
 }
 
-// getAV returns a list of AV systems that we support detecting
-func getAV() []AVDiscoverer {
-	allAV := []AVDiscoverer{}
-	for _, av := range AVSystems {
-		allAV = append(allAV, av)
+// avDetectors is the memoized result of loading the embedded signature pack
+// plus whatever --av-signatures points at.
+var avDetectors []avreg.Discoverer
+
+// getAV returns a Discoverer for every AV/EDR signature postex knows about,
+// loading and caching them on first use.
+func getAV() []avreg.Discoverer {
+	if avDetectors != nil {
+		return avDetectors
+	}
+	sigs, err := avreg.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "avreg:", err)
+	}
+	if *flag_av_sigs != "" {
+		extra, err := avreg.LoadFile(*flag_av_sigs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "avreg:", err)
+		} else {
+			sigs = append(sigs, extra...)
+		}
+	}
+	avDetectors = avreg.Detectors(sigs, procLister)
+	return avDetectors
+}
+
+// procLister adapts the shared ProcessMonitor to avreg.ProcLister, falling
+// back to a one-time /proc scan when the monitor couldn't be started (e.g.
+// no CAP_NET_ADMIN for its proc connector socket), so --av still works for
+// an unprivileged caller instead of silently seeing no processes at all.
+func procLister() []avreg.Process {
+	var procs []procmon.Process
+	if mon := procMonitor(); mon != nil {
+		procs = mon.Snapshot()
+	} else {
+		procs = procmon.Processes()
+	}
+	out := make([]avreg.Process, len(procs))
+	for i, p := range procs {
+		out[i] = avreg.Process{Pid: p.Pid, Name: p.Name}
 	}
-	return allAV
+	return out
 }
 
-// getWatches fetches a list of watches that auditd currently has on filesystem paths.
+// getWatches fetches the list of watches that auditd currently has on
+// filesystem paths, read live from the kernel's audit ruleset over
+// NETLINK_AUDIT, falling back to parsing auditd's on-disk rules file when
+// the netlink ruleset isn't readable (e.g. no CAP_AUDIT_READ).
 func getWatches() ([]watch, error) {
-	re := regexp.MustCompile("-w ([^[:space:]]+).* -p ([[:alpha:]]+)")
-	t, err := ioutil.ReadFile(AuditdRules)
-	found := []watch{}
+	aw, err := auditd.ListWatches()
 	if err != nil {
-		return nil, fmt.Errorf("Unable to open %v", AuditdRules)
-	}
-	for _, line := range strings.Split(string(t), "\n") {
-		matches := re.FindStringSubmatch(line)
-		if len(matches) == 3 {
-			found = append(found, watch{
-				path:   matches[1],
-				action: matches[2],
-			})
-		}
+		return nil, err
+	}
+	found := make([]watch, len(aw))
+	for i, w := range aw {
+		found[i] = watch{path: w.Path, action: w.Perm}
 	}
 	return found, nil
 }
 
+// netSnapshot fetches the current set of established connections as a
+// poll.NetSnapshot, ready to be diffed against a prior snapshot.
+func netSnapshot() poll.NetSnapshot {
+	snap := poll.NetSnapshot{}
+	mon := sockMonitor()
+	if mon == nil {
+		return snap
+	}
+	for _, s := range mon.Snapshot() {
+		snap[poll.Connection{
+			Proto: s.Proto, LocalIp: s.LocalIp, ForeignIp: s.ForeignIp,
+			LocalPort: s.LocalPort, ForeignPort: s.ForeignPort,
+		}] = true
+	}
+	return snap
+}
+
+// userSnapshot fetches the current set of logged-in users as a poll.UserSnapshot.
+func userSnapshot() poll.UserSnapshot {
+	snap := poll.UserSnapshot{}
+	for _, w := range getWho() {
+		snap[poll.Session{User: w.user, Host: w.host, Line: w.line, Pid: w.pid, Time: w.time}] = true
+	}
+	return snap
+}
+
+// runPoll drives p until SIGINT, reporting each event as it's diffed and,
+// if summary is true, a final pollSummaryFinding once polling stops.
+func runPoll(rep *reporter.Reporter, p *poll.Poller, summary bool) {
+	stop := make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	events := p.Run(stop)
+	go func() {
+		<-sigs
+		close(stop)
+	}()
+	for e := range events {
+		rep.Report(pollEventFinding{e})
+	}
+	if summary {
+		rep.Report(pollSummaryFinding{p.Summary()})
+	}
+}
+
+// runAuditStream subscribes to the kernel's live audit record feed and
+// reports each record until SIGINT.
+func runAuditStream(rep *reporter.Reporter) {
+	stop := make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	events, err := auditd.Stream(stop)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "audit-stream:", err)
+		return
+	}
+	go func() {
+		<-sigs
+		close(stop)
+	}()
+	for e := range events {
+		rep.Report(auditEventFinding{e})
+	}
+}
+
 // stalkUser perform an action when a specific user logs in at any point in the future.
 // If user == "*", any user will trigger the action.
 func stalkUser(user string, sa stalkAction) error {
@@ -340,86 +502,117 @@ func stalkUser(user string, sa stalkAction) error {
 	return nil
 }
 
+// newReporter builds the Reporter described by --format and --output.
+func newReporter() *reporter.Reporter {
+	sink, err := reporter.Open(*flag_output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return reporter.New(reporter.Format(*flag_format), sink)
+}
+
+// activeModes returns the sandbox.Policies keys for every recon flag the
+// user actually passed, so Install only opens up the syscalls that mode needs.
+func activeModes() []string {
+	modes := []string{}
+	add := func(on bool, name string) {
+		if on {
+			modes = append(modes, name)
+		}
+	}
+	add(*flag_gatt || *flag_pkeys, "pkeys")
+	add(*flag_gatt || *flag_av, "av")
+	add(*flag_gatt || *flag_container, "container")
+	add(*flag_gatt || *flag_net, "net")
+	add(*flag_gatt || *flag_watches, "watches")
+	add(*flag_gatt || *flag_arp, "arp")
+	add(*flag_gatt || *flag_who, "who")
+	add(*flag_poll_net, "pollnet")
+	add(*flag_poll_users, "pollusers")
+	add(*flag_audit_stream, "audit-stream")
+	add(*flag_stalk != "", "who")
+	return modes
+}
+
 func main() {
 	flag.Parse()
+	// The output sink is opened before the sandbox goes in, since a file or
+	// syslog destination needs syscalls most mode policies don't allow.
+	rep := newReporter()
+	defer rep.Close()
+	if !*flag_no_sandbox {
+		if err := sandbox.Install(sandbox.Merge(activeModes()...)); err != nil {
+			// A sandbox that silently fails to install is worse than no
+			// sandbox at all: callers would believe postex is bounded to
+			// its declared syscalls when it isn't. Fail closed instead.
+			fmt.Fprintln(os.Stderr, "sandbox:", err)
+			os.Exit(1)
+		}
+	}
+
 	if *flag_gatt || *flag_container {
-		fmt.Printf("isContainer: %v\n", isContainer())
+		rep.Report(containerFinding{detection: container.Detect()})
 	}
 	if *flag_gatt || *flag_pkeys {
-		fmt.Printf("ssh keys:")
 		for _, dir := range strings.Split(*flag_pkey_dirs, ",") {
 			for _, pkey := range getSSHKeys(dir, *flag_pkey_sleep) {
-				fmt.Printf("\n\tfile=%v encrypted=%v", pkey.path, pkey.encrypted)
+				rep.Report(pkey)
 			}
 		}
-		fmt.Println("")
 	}
 	if *flag_gatt || *flag_av {
-		fmt.Printf("AV:")
-		for _, av := range AVSystems {
-			name, paths, procs, mods := av.Name(), av.Paths(), av.Procs(), av.KernelModules()
-			if len(paths) > 0 || len(procs) > 0 {
-				fmt.Printf("\n\tname=%s files=%v procs=%v, modules=%v", name, paths, procs, mods)
+		for _, av := range getAV() {
+			paths, procs, mods := av.Paths(), av.Procs(), av.KernelModules()
+			ports, units, configs := av.Ports(), av.SystemdUnits(), av.ConfigFiles()
+			if len(paths) > 0 || len(procs) > 0 || len(mods) > 0 || len(ports) > 0 || len(units) > 0 || len(configs) > 0 {
+				rep.Report(avFinding{
+					name: av.Name(), paths: paths, procs: procs, mods: mods,
+					ports: ports, systemdUnits: units, configFiles: configs,
+				})
 			}
 		}
-		fmt.Println("")
 	}
 	if *flag_gatt || *flag_net {
-		fmt.Printf("ipv4 connections:")
-		for _, conn := range netstat.Tcp() {
-			if conn.State == "ESTABLISHED" {
-				fmt.Printf("\n\t tcp4: %s:%d <> %s:%d", conn.Ip, conn.Port, conn.ForeignIp, conn.ForeignPort)
-			}
-		}
-		fmt.Println("")
-		for _, conn := range netstat.Udp() {
-			if conn.State == "ESTABLISHED" {
-				fmt.Printf("\n\t udp4: %s:%d <> %s:%d", conn.Ip, conn.Port, conn.ForeignIp, conn.ForeignPort)
-			}
-		}
-
-		fmt.Printf("\nipv6 connections:")
-		for _, conn := range netstat.Tcp6() {
-			if conn.State == "ESTABLISHED" {
-				fmt.Printf("\n\t tcp6: %s:%d <> %s:%d", conn.Ip, conn.Port, conn.ForeignIp, conn.ForeignPort)
+		if mon := sockMonitor(); mon != nil {
+			for _, s := range mon.Snapshot() {
+				rep.Report(connectionFinding{s.Proto, s.LocalIp, s.ForeignIp, s.LocalPort, s.ForeignPort})
 			}
 		}
-		fmt.Println("")
-		for _, conn := range netstat.Udp6() {
-			if conn.State == "ESTABLISHED" {
-				fmt.Printf("\n\t udp6: %s:%d <> %s:%d", conn.Ip, conn.Port, conn.ForeignIp, conn.ForeignPort)
-			}
-		}
-		fmt.Println("")
 	}
 	if *flag_gatt || *flag_watches {
-		fmt.Printf("Watches:")
 		watches, err := getWatches()
 		if err != nil {
-			fmt.Println("Error checking watches: ", err)
+			fmt.Fprintln(os.Stderr, "Error checking watches: ", err)
 		} else {
 			for _, w := range watches {
-				fmt.Printf("\n\tpath=%v action=%v", w.path, w.action)
+				rep.Report(w)
 			}
 		}
-		fmt.Println("")
 	}
 	if *flag_gatt || *flag_arp {
-		fmt.Printf("ARP table:")
 		for _, arp := range getArp() {
-			fmt.Printf("\n\tmac=%s ip=%s", arp.HardwareAddr, arp.IP)
+			rep.Report(arpFinding{mac: arp.HardwareAddr.String(), ip: arp.IP.String()})
 		}
-		fmt.Println("")
 	}
 	if *flag_gatt || *flag_who {
-		fmt.Printf("Logged in:")
 		for _, w := range getWho() {
-			t := time.Unix(int64(w.time), 0)
-			fmt.Printf("\n\tuser=%s host=%s line=%s pid=%d login_time=%d (%s)", w.user, w.host, w.line, w.pid, w.time, t)
+			rep.Report(w)
 		}
-		fmt.Println("")
+	}
+	if *flag_poll_net {
+		runPoll(rep, poll.NewNetPoller(*flag_poll_every, *flag_poll_dedup, netSnapshot), *flag_poll_summary)
+	}
+	if *flag_poll_users {
+		runPoll(rep, poll.NewUserPoller(*flag_poll_every, *flag_poll_dedup, userSnapshot), *flag_poll_summary)
+	}
+	if *flag_audit_stream {
+		runAuditStream(rep)
 	}
 	if *flag_stalk != "" {
-		stalkUser(*flag_stalk, func(user string) error { fmt.Printf("User logged in! %s", user); return nil })
+		stalkUser(*flag_stalk, func(user string) error {
+			fmt.Printf("User logged in! %s", user)
+			return nil
+		})
 	}
 }
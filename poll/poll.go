@@ -0,0 +1,192 @@
+// Package poll implements long-poll diffing of recon snapshots (network
+// connections, logged-in users) into discrete open/close and login/logout
+// events, with optional per-event dedup and an aggregate summary for
+// reporting at exit.
+package poll
+
+import "time"
+
+// EventKind identifies what changed between two snapshots.
+type EventKind string
+
+const (
+	ConnectionOpened EventKind = "connection_opened"
+	ConnectionClosed EventKind = "connection_closed"
+	UserLogin        EventKind = "user_login"
+	UserLogout       EventKind = "user_logout"
+)
+
+// Connection is a comparable view of a single established connection, used
+// as a snapshot key so two polls can be diffed with plain map lookups.
+type Connection struct {
+	Proto                  string
+	LocalIp, ForeignIp     string
+	LocalPort, ForeignPort uint16
+}
+
+// Session is a comparable view of a single logged-in user.
+type Session struct {
+	User, Host, Line string
+	Pid              int32
+	Time             int32
+}
+
+// Event is a single diffed change, emitted with the time it was observed.
+type Event struct {
+	Kind       EventKind
+	Connection Connection
+	Session    Session
+	At         time.Time
+}
+
+// NetSnapshot is a point-in-time set of established connections.
+type NetSnapshot map[Connection]bool
+
+// UserSnapshot is a point-in-time set of logged-in sessions.
+type UserSnapshot map[Session]bool
+
+// diffNet compares two NetSnapshots and returns the events between them.
+func diffNet(prev, cur NetSnapshot, at time.Time) []Event {
+	events := []Event{}
+	for c := range cur {
+		if !prev[c] {
+			events = append(events, Event{Kind: ConnectionOpened, Connection: c, At: at})
+		}
+	}
+	for c := range prev {
+		if !cur[c] {
+			events = append(events, Event{Kind: ConnectionClosed, Connection: c, At: at})
+		}
+	}
+	return events
+}
+
+// diffUsers compares two UserSnapshots and returns the events between them.
+func diffUsers(prev, cur UserSnapshot, at time.Time) []Event {
+	events := []Event{}
+	for s := range cur {
+		if !prev[s] {
+			events = append(events, Event{Kind: UserLogin, Session: s, At: at})
+		}
+	}
+	for s := range prev {
+		if !cur[s] {
+			events = append(events, Event{Kind: UserLogout, Session: s, At: at})
+		}
+	}
+	return events
+}
+
+// dedupKey identifies an event for suppression purposes, independent of its
+// At time. Kind is part of the key so an open and its matching close (or a
+// login and its matching logout) dedup independently rather than sharing a
+// window, which would otherwise drop the close/logout entirely whenever it
+// follows the open/login within the dedup window.
+func dedupKey(e Event) interface{} {
+	if e.Kind == ConnectionOpened || e.Kind == ConnectionClosed {
+		return struct {
+			Kind EventKind
+			Connection
+		}{e.Kind, e.Connection}
+	}
+	return struct {
+		Kind EventKind
+		Session
+	}{e.Kind, e.Session}
+}
+
+// Poller periodically takes a snapshot via collect, diffs it against the
+// previous one, and sends the resulting events on the returned channel. The
+// same Poller is used for both --pollnet and --pollusers; callers supply a
+// collect function that produces either a NetSnapshot or a UserSnapshot.
+type Poller struct {
+	// Interval between snapshots.
+	Interval time.Duration
+	// Dedup suppresses repeat events for the same connection/session within
+	// this window. Zero disables dedup.
+	Dedup time.Duration
+
+	collect func() (NetSnapshot, UserSnapshot)
+	last    map[interface{}]time.Time
+	summary *Summary
+}
+
+// NewNetPoller returns a Poller that diffs NetSnapshots produced by collect.
+func NewNetPoller(interval, dedup time.Duration, collect func() NetSnapshot) *Poller {
+	return &Poller{
+		Interval: interval,
+		Dedup:    dedup,
+		collect:  func() (NetSnapshot, UserSnapshot) { return collect(), nil },
+		last:     map[interface{}]time.Time{},
+		summary:  newSummary(),
+	}
+}
+
+// NewUserPoller returns a Poller that diffs UserSnapshots produced by collect.
+func NewUserPoller(interval, dedup time.Duration, collect func() UserSnapshot) *Poller {
+	return &Poller{
+		Interval: interval,
+		Dedup:    dedup,
+		collect:  func() (NetSnapshot, UserSnapshot) { return nil, collect() },
+		last:     map[interface{}]time.Time{},
+		summary:  newSummary(),
+	}
+}
+
+// Run polls at Interval until stop is closed, sending deduplicated Events on
+// the returned channel. The channel is closed once stop is closed and the
+// polling goroutine exits.
+func (p *Poller) Run(stop <-chan struct{}) <-chan Event {
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+
+		prevNet, prevUsers := p.collect()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				curNet, curUsers := p.collect()
+				var events []Event
+				if curNet != nil {
+					events = diffNet(prevNet, curNet, time.Now())
+					prevNet = curNet
+				} else {
+					events = diffUsers(prevUsers, curUsers, time.Now())
+					prevUsers = curUsers
+				}
+				for _, e := range events {
+					if p.suppressed(e) {
+						continue
+					}
+					p.summary.observe(e)
+					out <- e
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// suppressed reports whether e should be dropped because an identical event
+// was already emitted within the Dedup window.
+func (p *Poller) suppressed(e Event) bool {
+	if p.Dedup == 0 {
+		return false
+	}
+	key := dedupKey(e)
+	if last, ok := p.last[key]; ok && e.At.Sub(last) < p.Dedup {
+		return true
+	}
+	p.last[key] = e.At
+	return false
+}
+
+// Summary returns the aggregate counts observed so far, suitable for a
+// "summary at exit" report.
+func (p *Poller) Summary() Summary {
+	return *p.summary
+}
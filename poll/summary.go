@@ -0,0 +1,74 @@
+package poll
+
+import "time"
+
+// Summary aggregates counts over the lifetime of a Poller so a caller can
+// print a "summary at exit" report (e.g. on SIGINT) instead of, or in
+// addition to, streaming individual events.
+type Summary struct {
+	// UniquePeers maps a foreign IP to the number of connections opened to/from it.
+	UniquePeers map[string]int
+	// ConnectionsOpened and ConnectionsClosed are running totals.
+	ConnectionsOpened, ConnectionsClosed int
+	// Logins and Logouts are running totals.
+	Logins, Logouts int
+	// sessionStart records when each currently-open session began, so
+	// SessionDurations can compute how long it lasted once it ends.
+	sessionStart map[Session]time.Time
+	// SessionDurations holds the duration of every session that has logged out.
+	SessionDurations []time.Duration
+}
+
+// newSummary returns an empty Summary ready to observe Events.
+func newSummary() *Summary {
+	return &Summary{
+		UniquePeers:  map[string]int{},
+		sessionStart: map[Session]time.Time{},
+	}
+}
+
+// observe folds a single Event into the running Summary.
+func (s *Summary) observe(e Event) {
+	switch e.Kind {
+	case ConnectionOpened:
+		s.ConnectionsOpened++
+		s.UniquePeers[e.Connection.ForeignIp]++
+	case ConnectionClosed:
+		s.ConnectionsClosed++
+	case UserLogin:
+		s.Logins++
+		s.sessionStart[e.Session] = e.At
+	case UserLogout:
+		s.Logouts++
+		if start, ok := s.sessionStart[e.Session]; ok {
+			s.SessionDurations = append(s.SessionDurations, e.At.Sub(start))
+			delete(s.sessionStart, e.Session)
+		}
+	}
+}
+
+// TopTalkers returns the n foreign IPs with the most opened connections,
+// most active first.
+func (s *Summary) TopTalkers(n int) []string {
+	type peerCount struct {
+		ip    string
+		count int
+	}
+	peers := make([]peerCount, 0, len(s.UniquePeers))
+	for ip, count := range s.UniquePeers {
+		peers = append(peers, peerCount{ip, count})
+	}
+	for i := 1; i < len(peers); i++ {
+		for j := i; j > 0 && peers[j].count > peers[j-1].count; j-- {
+			peers[j], peers[j-1] = peers[j-1], peers[j]
+		}
+	}
+	if n > len(peers) {
+		n = len(peers)
+	}
+	top := make([]string, n)
+	for i := 0; i < n; i++ {
+		top[i] = peers[i].ip
+	}
+	return top
+}
@@ -0,0 +1,72 @@
+// Package procmon replaces the periodic /proc and /proc/net polling
+// previously used for process and connection discovery with push
+// notifications from the kernel: the process-events connector
+// (NETLINK_CONNECTOR / CN_IDX_PROC) for fork/exec/exit, and socket
+// diagnostics (NETLINK_SOCK_DIAG) for connection state. The process monitor
+// seeds itself from a one-time /proc scan and then stays live from kernel
+// events without re-polling, so short-lived processes that a ps snapshot
+// would miss between polls are still observed. sock_diag has no push mode,
+// so the socket monitor re-dumps it on an interval; a connection that opens
+// and closes between two dumps is not seen.
+package procmon
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// nlRequest sends a netlink request on fd built from header+payload and
+// returns the payload of every message in the multipart response, collected
+// until NLMSG_DONE.
+func nlRequest(fd int, header []byte, payload []byte) ([][]byte, error) {
+	msg := append(append([]byte{}, header...), payload...)
+	if err := unix.Sendto(fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("procmon: sendto: %v", err)
+	}
+	var parts [][]byte
+	buf := make([]byte, 32*1024)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("procmon: recvfrom: %v", err)
+		}
+		for off := 0; off+unix.NLMSG_HDRLEN <= n; {
+			l := int(nlLen(buf[off:]))
+			if l < unix.NLMSG_HDRLEN || off+l > n {
+				break
+			}
+			switch nlType(buf[off:]) {
+			case unix.NLMSG_DONE:
+				return parts, nil
+			case unix.NLMSG_ERROR:
+				return parts, fmt.Errorf("procmon: netlink error response")
+			default:
+				parts = append(parts, append([]byte{}, buf[off+unix.NLMSG_HDRLEN:off+l]...))
+			}
+			off += nlAlign(l)
+		}
+	}
+}
+
+func nlLen(b []byte) uint32  { return binary.LittleEndian.Uint32(b[0:4]) }
+func nlType(b []byte) uint16 { return binary.LittleEndian.Uint16(b[4:6]) }
+
+// nlAlign rounds l up to NLMSG_ALIGNTO (4 bytes), matching NLMSG_ALIGN.
+func nlAlign(l int) int {
+	return (l + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+}
+
+// nlHeader builds a struct nlmsghdr for a request of msgType carrying
+// payloadLen bytes, with NLM_F_REQUEST (and NLM_F_DUMP for multi-record
+// responses like socket diag dumps).
+func nlHeader(msgType uint16, flags uint16, payloadLen int) []byte {
+	h := make([]byte, unix.NLMSG_HDRLEN)
+	binary.LittleEndian.PutUint32(h[0:4], uint32(unix.NLMSG_HDRLEN+payloadLen))
+	binary.LittleEndian.PutUint16(h[4:6], msgType)
+	binary.LittleEndian.PutUint16(h[6:8], flags)
+	// sequence and pid are left zero; the kernel doesn't require them for
+	// single-socket request/response use.
+	return h
+}
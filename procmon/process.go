@@ -0,0 +1,249 @@
+package procmon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// Proc connector constants from linux/cn_proc.h / linux/connector.h. These
+// aren't exposed by golang.org/x/sys/unix, so we define them ourselves.
+const (
+	cnIdxProc         = 0x1
+	cnValProc         = 0x1
+	procCnMcastListen = 1
+	procCnMcastIgnore = 2
+
+	procEventFork = 0x00000001
+	procEventExec = 0x00000002
+	procEventExit = 0x80000000
+
+	cnMsgHdrLen = 20 // struct cn_msg header: id, seq, ack, len, flags
+)
+
+// ProcEventType identifies the kind of process lifecycle event received from
+// the kernel's proc connector.
+type ProcEventType string
+
+const (
+	ProcFork ProcEventType = "fork"
+	ProcExec ProcEventType = "exec"
+	ProcExit ProcEventType = "exit"
+)
+
+// ProcEvent is a single fork/exec/exit notification for a PID.
+type ProcEvent struct {
+	Type ProcEventType
+	Pid  int
+	// PPid is populated for fork events.
+	PPid int
+	// ExitCode is populated for exit events.
+	ExitCode int
+}
+
+// Process is a lightweight, comparable view of a running process, mirroring
+// what the rest of postex previously got from ps.Processes().
+type Process struct {
+	Pid  int
+	Name string
+}
+
+// ProcessMonitor maintains a live pid->name table, seeded from /proc and
+// kept current by proc connector events, so callers never need to re-scan
+// /proc to answer "what's running right now".
+type ProcessMonitor struct {
+	fd int
+
+	mu    sync.Mutex
+	procs map[int]string
+
+	events chan ProcEvent
+	done   chan struct{}
+}
+
+// NewProcessMonitor opens a proc connector netlink socket, subscribes to
+// process events, seeds its table from a one-time /proc scan, and starts
+// consuming kernel events in the background. Requires CAP_NET_ADMIN.
+func NewProcessMonitor() (*ProcessMonitor, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM, unix.NETLINK_CONNECTOR)
+	if err != nil {
+		return nil, fmt.Errorf("procmon: socket: %v", err)
+	}
+	sa := &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: cnIdxProc}
+	if err := unix.Bind(fd, sa); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("procmon: bind: %v", err)
+	}
+
+	m := &ProcessMonitor{
+		fd:     fd,
+		procs:  scanProc(),
+		events: make(chan ProcEvent, 64),
+		done:   make(chan struct{}),
+	}
+	if err := m.listen(); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	go m.run()
+	return m, nil
+}
+
+// listen sends the PROC_CN_MCAST_LISTEN control message asking the kernel
+// to start delivering process events to this socket.
+func (m *ProcessMonitor) listen() error {
+	payload := make([]byte, cnMsgHdrLen+4)
+	binary.LittleEndian.PutUint32(payload[0:4], cnIdxProc)  // cn_msg.id.idx
+	binary.LittleEndian.PutUint32(payload[4:8], cnValProc)  // cn_msg.id.val
+	binary.LittleEndian.PutUint32(payload[16:20], 4)        // cn_msg.len
+	binary.LittleEndian.PutUint32(payload[20:24], procCnMcastListen)
+
+	header := nlHeader(unix.NLMSG_DONE, unix.NLM_F_REQUEST, len(payload))
+	msg := append(header, payload...)
+	return unix.Sendto(m.fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK})
+}
+
+// run drains netlink messages, decodes proc events, updates the live
+// table, and forwards each event on Events().
+func (m *ProcessMonitor) run() {
+	defer close(m.events)
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := unix.Recvfrom(m.fd, buf, 0)
+		if err != nil {
+			return
+		}
+		ev, ok := parseProcEvent(buf[:n])
+		if !ok {
+			continue
+		}
+		m.apply(ev)
+		select {
+		case m.events <- ev:
+		case <-m.done:
+			return
+		default:
+		}
+	}
+}
+
+// apply updates the live pid table for a single event.
+func (m *ProcessMonitor) apply(ev ProcEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch ev.Type {
+	case ProcExec:
+		if name, err := readComm(ev.Pid); err == nil {
+			m.procs[ev.Pid] = name
+		}
+	case ProcExit:
+		delete(m.procs, ev.Pid)
+	}
+}
+
+// Events returns a channel of process lifecycle events as the kernel
+// reports them. The channel is closed when Close is called. An event is
+// dropped when the channel's buffer is full, so the live table keeps
+// updating whether or not anyone reads Events.
+func (m *ProcessMonitor) Events() <-chan ProcEvent { return m.events }
+
+// Snapshot returns every process the monitor currently believes is running.
+func (m *ProcessMonitor) Snapshot() []Process {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Process, 0, len(m.procs))
+	for pid, name := range m.procs {
+		out = append(out, Process{Pid: pid, Name: name})
+	}
+	return out
+}
+
+// Close stops the monitor and releases its netlink socket.
+func (m *ProcessMonitor) Close() error {
+	close(m.done)
+	return unix.Close(m.fd)
+}
+
+// Processes takes a one-time snapshot of running processes straight from
+// /proc, with no netlink socket involved. It's the fallback for callers
+// that can't get a ProcessMonitor going (NewProcessMonitor needs
+// CAP_NET_ADMIN to open a proc connector socket, which an unprivileged
+// caller or a restricted container may not have).
+func Processes() []Process {
+	procs := scanProc()
+	out := make([]Process, 0, len(procs))
+	for pid, name := range procs {
+		out = append(out, Process{Pid: pid, Name: name})
+	}
+	return out
+}
+
+// scanProc seeds the initial pid->name table from /proc, since the proc
+// connector only reports events going forward from when it was subscribed.
+func scanProc() map[int]string {
+	procs := map[int]string{}
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return procs
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if name, err := readComm(pid); err == nil {
+			procs[pid] = name
+		}
+	}
+	return procs
+}
+
+// readComm reads the executable name for pid from /proc/<pid>/comm.
+func readComm(pid int) (string, error) {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// parseProcEvent decodes a single proc connector netlink message into a
+// ProcEvent, per struct nlmsghdr { struct cn_msg { struct proc_event } }.
+func parseProcEvent(b []byte) (ProcEvent, bool) {
+	if len(b) < unix.NLMSG_HDRLEN+cnMsgHdrLen+4 {
+		return ProcEvent{}, false
+	}
+	body := b[unix.NLMSG_HDRLEN+cnMsgHdrLen:]
+	what := binary.LittleEndian.Uint32(body[0:4])
+	// proc_event: what(4) cpu(4) timestamp(8) union{...}
+	data := body[16:]
+	switch what {
+	case procEventFork:
+		if len(data) < 16 {
+			return ProcEvent{}, false
+		}
+		ppid := int(binary.LittleEndian.Uint32(data[0:4]))
+		pid := int(binary.LittleEndian.Uint32(data[8:12]))
+		return ProcEvent{Type: ProcFork, Pid: pid, PPid: ppid}, true
+	case procEventExec:
+		if len(data) < 8 {
+			return ProcEvent{}, false
+		}
+		pid := int(binary.LittleEndian.Uint32(data[0:4]))
+		return ProcEvent{Type: ProcExec, Pid: pid}, true
+	case procEventExit:
+		if len(data) < 16 {
+			return ProcEvent{}, false
+		}
+		pid := int(binary.LittleEndian.Uint32(data[0:4]))
+		code := int(int32(binary.LittleEndian.Uint32(data[8:12])))
+		return ProcEvent{Type: ProcExit, Pid: pid, ExitCode: code}, true
+	default:
+		return ProcEvent{}, false
+	}
+}
@@ -0,0 +1,156 @@
+// Package reporter turns postex recon results into a stream of Findings that
+// can be written as human-readable text or structured JSON/NDJSON, and
+// shipped to stdout, a file, or a remote sink such as syslog or HTTP. It
+// exists so the recon code in package main never has to know how its output
+// will ultimately be consumed.
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Format selects how a Finding is serialized before it reaches a sink.
+type Format string
+
+const (
+	// FormatText renders findings the way postex historically printed them:
+	// one line per finding, fields appended as key=value pairs.
+	FormatText Format = "text"
+	// FormatJSON buffers every finding and emits a single JSON array on Close.
+	FormatJSON Format = "json"
+	// FormatNDJSON emits one JSON object per line as findings arrive.
+	FormatNDJSON Format = "ndjson"
+)
+
+// Finding is implemented by every recon result postex produces (private
+// keys, AV hits, network connections, ARP entries, logged-in users,
+// watches, container detection, poll events, ...) so the Reporter can
+// serialize and route them without a type switch per caller.
+type Finding interface {
+	// Kind identifies the category of finding, e.g. "private_key", "connection".
+	Kind() string
+	// Fields returns the finding's data as key/value pairs for formatting.
+	Fields() map[string]interface{}
+}
+
+// Reporter streams Findings to sink in the given Format.
+type Reporter struct {
+	format Format
+	sink   io.Writer
+
+	// jsonBuf accumulates findings for FormatJSON, which must emit a single
+	// well-formed array rather than one object per Report call.
+	jsonBuf []Finding
+}
+
+// New returns a Reporter that writes to sink using format.
+func New(format Format, sink io.Writer) *Reporter {
+	return &Reporter{format: format, sink: sink}
+}
+
+// Report serializes f according to the Reporter's format and writes it to
+// the sink. For FormatJSON the finding is buffered until Close.
+func (r *Reporter) Report(f Finding) error {
+	switch r.format {
+	case FormatJSON:
+		r.jsonBuf = append(r.jsonBuf, f)
+		return nil
+	case FormatNDJSON:
+		b, err := json.Marshal(withKind(f))
+		if err != nil {
+			return err
+		}
+		_, err = r.sink.Write(append(b, '\n'))
+		return err
+	default:
+		_, err := fmt.Fprintln(r.sink, formatText(f))
+		return err
+	}
+}
+
+// Close flushes any buffered output and releases the sink if it supports
+// closing. Callers must call Close when they're done reporting, typically
+// via defer.
+func (r *Reporter) Close() error {
+	if r.format == FormatJSON {
+		out := make([]map[string]interface{}, 0, len(r.jsonBuf))
+		for _, f := range r.jsonBuf {
+			out = append(out, withKind(f))
+		}
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := r.sink.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	if c, ok := r.sink.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// withKind flattens a Finding's fields into a map with its kind attached,
+// ready for JSON encoding.
+func withKind(f Finding) map[string]interface{} {
+	fields := f.Fields()
+	out := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		out[k] = v
+	}
+	out["kind"] = f.Kind()
+	return out
+}
+
+// formatText renders a Finding the way postex has always printed results:
+// "kind: key=value key=value ...", with keys sorted for stable output.
+func formatText(f Finding) string {
+	fields := f.Fields()
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s:", f.Kind())
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, fields[k])
+	}
+	return b.String()
+}
+
+// httpSink POSTs every write as the body of a request to endpoint, letting
+// a Reporter ship NDJSON lines (or a final JSON array) to a collector.
+type httpSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPSink returns a sink that POSTs each Write to endpoint.
+func NewHTTPSink(endpoint string) io.WriteCloser {
+	return &httpSink{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (h *httpSink) Write(p []byte) (int, error) {
+	resp, err := h.client.Post(h.endpoint, "application/json", bytes.NewReader(p))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("reporter: http sink %s returned %s", h.endpoint, resp.Status)
+	}
+	return len(p), nil
+}
+
+func (h *httpSink) Close() error { return nil }
@@ -0,0 +1,37 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/url"
+	"os"
+)
+
+// Open resolves a --output destination string into a sink suitable for New.
+// An empty dest yields stdout. Supported forms:
+//
+//	""                     stdout
+//	/path/to/file          append to a local file, creating it if needed
+//	syslog://              local syslog daemon, tagged "postex"
+//	http://host/path       HTTP collector, one POST per write
+//	https://host/path      same, over TLS
+func Open(dest string) (io.Writer, error) {
+	if dest == "" {
+		return os.Stdout, nil
+	}
+	u, err := url.Parse(dest)
+	if err == nil {
+		switch u.Scheme {
+		case "syslog":
+			return syslog.New(syslog.LOG_INFO, "postex")
+		case "http", "https":
+			return NewHTTPSink(dest), nil
+		}
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("reporter: unable to open output %q: %v", dest, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,116 @@
+package sandbox
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// seccomp_data field offsets, from linux/seccomp.h / linux/filter.h.
+const (
+	seccompDataOffNr   = 0
+	seccompDataOffArch = 4
+)
+
+// auditArchX86_64 is AUDIT_ARCH_X86_64 from linux/audit.h. Our syscall
+// table below is x86_64-specific, so a filter built from it must refuse to
+// run under any other personality (e.g. a 32-bit compat syscall entry
+// point), rather than silently misinterpreting syscall numbers.
+const auditArchX86_64 = 0xc000003e
+
+// SECCOMP_RET_* actions, from linux/seccomp.h.
+const (
+	secRetKillProcess = 0x80000000
+	secRetAllow       = 0x7fff0000
+	secRetErrnoBase   = 0x00050000
+)
+
+// secSetModeFilter is SECCOMP_SET_MODE_FILTER from linux/seccomp.h, not
+// exposed by golang.org/x/sys/unix.
+const secSetModeFilter = 1
+
+// syscallNumbers maps syscall names to their x86_64 numbers. Only the
+// syscalls referenced by Policies and baselinePolicy need an entry.
+var syscallNumbers = map[Syscall]uint32{
+	"read": 0, "write": 1, "close": 3, "fstat": 5, "lseek": 8,
+	"mmap": 9, "mprotect": 10, "munmap": 11, "brk": 12,
+	"rt_sigaction": 13, "rt_sigprocmask": 14, "rt_sigreturn": 15,
+	"pipe2": 293, "nanosleep": 35, "socket": 41, "bind": 49, "connect": 42,
+	"sendto": 44, "recvfrom": 45, "setsockopt": 54, "clone": 56,
+	"exit": 60, "exit_group": 231, "sigaltstack": 131, "gettid": 186,
+	"readlink": 89, "tgkill": 234, "sched_yield": 24,
+	"sched_getaffinity": 204, "set_tid_address": 218, "getdents64": 217,
+	"openat": 257, "newfstatat": 262, "set_robust_list": 273,
+	"epoll_ctl": 233, "epoll_create1": 291, "epoll_pwait": 281,
+	"getrandom": 318, "rseq": 334, "prctl": 157, "arch_prctl": 158,
+	"clone3": 435, "futex": 202,
+}
+
+// Install compiles p into a seccomp-bpf program and applies it to the
+// calling thread via SECCOMP_SET_MODE_FILTER. Every OS thread the Go
+// runtime later starts inherits the filter, since PR_SET_NO_NEW_PRIVS and
+// seccomp filters are fork/clone-inherited thread attributes. Install must
+// run after flag parsing and before any recon code, since once applied,
+// any syscall outside p's Allow/Deny lists kills the process.
+func Install(p Policy) error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("sandbox: PR_SET_NO_NEW_PRIVS: %v", err)
+	}
+	prog, err := compile(p)
+	if err != nil {
+		return fmt.Errorf("sandbox: compile policy %q: %v", p.Name, err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_SECCOMP, uintptr(secSetModeFilter), 0, uintptr(unsafe.Pointer(prog))); errno != 0 {
+		return fmt.Errorf("sandbox: SECCOMP_SET_MODE_FILTER: %v", errno)
+	}
+	return nil
+}
+
+// compile turns p into a linear BPF program: refuse anything but the
+// x86_64 ABI, then for each allowed syscall compare-and-return-ALLOW, for
+// each denied syscall compare-and-return-ERRNO, and kill the process for
+// everything that falls through.
+func compile(p Policy) (*unix.SockFprog, error) {
+	prog := []unix.SockFilter{
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataOffArch),
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, auditArchX86_64, 1, 0),
+		ret(secRetKillProcess),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataOffNr),
+	}
+	for _, s := range p.Allow {
+		nr, ok := syscallNumbers[s]
+		if !ok {
+			return nil, fmt.Errorf("no syscall number for %q", s)
+		}
+		prog = append(prog,
+			jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, 0, 1),
+			ret(secRetAllow),
+		)
+	}
+	for s, errno := range p.Deny {
+		nr, ok := syscallNumbers[s]
+		if !ok {
+			return nil, fmt.Errorf("no syscall number for %q", s)
+		}
+		prog = append(prog,
+			jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, 0, 1),
+			ret(secRetErrnoBase|(uint32(errno)&0xffff)),
+		)
+	}
+	prog = append(prog, ret(secRetKillProcess))
+
+	return &unix.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}, nil
+}
+
+func stmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func jump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
+
+func ret(k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: k}
+}
@@ -0,0 +1,117 @@
+// Package sandbox installs a seccomp-bpf filter that restricts postex to
+// the syscalls its active subcommands actually need. Policies are
+// expressed as plain allow/deny lists per subcommand (a tiny DSL rather
+// than hand-written BPF) and compiled into a BPF program at startup, so a
+// captured binary can't be pivoted into behavior outside what the flags it
+// was invoked with require.
+package sandbox
+
+// Syscall is a Linux syscall name as used in a Policy.
+type Syscall string
+
+// Errno is the value a denied syscall returns to its caller instead of
+// running or killing the process.
+type Errno int
+
+// Policy lists the syscalls one postex subcommand needs, on top of the
+// runtime baseline every mode gets regardless of flags (see baselinePolicy).
+type Policy struct {
+	Name string
+	// Allow syscalls are permitted to run normally.
+	Allow []Syscall
+	// Deny maps a syscall name to the errno it should return rather than
+	// being allowed to run or falling through to the default kill action.
+	Deny map[Syscall]Errno
+}
+
+// baselinePolicy lists syscalls the Go runtime and postex's own startup
+// path need regardless of which recon modes are active.
+var baselinePolicy = Policy{
+	Name: "baseline",
+	Allow: []Syscall{
+		"read", "write", "close", "fstat", "newfstatat", "lseek", "mmap", "munmap", "mprotect",
+		"brk", "rt_sigaction", "rt_sigprocmask", "rt_sigreturn", "sigaltstack",
+		"clone", "clone3", "futex", "sched_getaffinity", "sched_yield",
+		"exit", "exit_group", "nanosleep", "epoll_create1", "epoll_ctl", "epoll_pwait",
+		"pipe2", "rseq", "set_robust_list", "getrandom", "arch_prctl", "prctl",
+		"set_tid_address", "tgkill", "gettid",
+	},
+}
+
+// Policies is the set of per-subcommand syscall requirements layered on top
+// of baselinePolicy. Merge unions baseline with whichever of these
+// correspond to the flags actually passed on the command line.
+var Policies = map[string]Policy{
+	"pkeys": {
+		Name:  "pkeys",
+		Allow: []Syscall{"openat", "read", "close", "getdents64", "newfstatat", "nanosleep"},
+	},
+	"av": {
+		// Procs() walks the procmon.ProcessMonitor's live table, which is
+		// seeded and updated over a proc connector netlink socket.
+		Name:  "av",
+		Allow: []Syscall{"openat", "read", "close", "newfstatat", "getdents64", "socket", "bind", "sendto", "recvfrom"},
+	},
+	"container": {
+		// container.Detect() only reads marker files and procfs; it no
+		// longer consults the ProcessMonitor, so no netlink syscalls here.
+		Name:  "container",
+		Allow: []Syscall{"openat", "read", "close", "readlink", "newfstatat"},
+	},
+	"net": {
+		Name:  "net",
+		Allow: []Syscall{"socket", "bind", "sendto", "recvfrom", "setsockopt", "close"},
+	},
+	"watches": {
+		// getWatches() talks to the kernel's audit subsystem over
+		// NETLINK_AUDIT, falling back to reading /etc/audit/audit.rules
+		// when the netlink ruleset isn't readable (no CAP_AUDIT_READ).
+		Name:  "watches",
+		Allow: []Syscall{"socket", "bind", "sendto", "recvfrom", "close", "openat", "read"},
+	},
+	"arp": {
+		Name:  "arp",
+		Allow: []Syscall{"socket", "bind", "sendto", "recvfrom", "close"},
+	},
+	"who": {
+		Name:  "who",
+		Allow: []Syscall{"openat", "read", "close", "lseek"},
+	},
+	"pollnet": {
+		Name:  "pollnet",
+		Allow: []Syscall{"socket", "bind", "sendto", "recvfrom", "setsockopt", "close"},
+	},
+	"pollusers": {
+		Name:  "pollusers",
+		Allow: []Syscall{"openat", "read", "close", "lseek"},
+	},
+	"audit-stream": {
+		Name:  "audit-stream",
+		Allow: []Syscall{"socket", "bind", "sendto", "recvfrom", "close"},
+	},
+}
+
+// Merge returns a Policy that's the union of baselinePolicy and every named
+// policy in active, with duplicate syscalls and Deny entries collapsed.
+func Merge(active ...string) Policy {
+	merged := Policy{Name: "postex", Deny: map[Syscall]Errno{}}
+	seen := map[Syscall]bool{}
+	add := func(p Policy) {
+		for _, s := range p.Allow {
+			if !seen[s] {
+				seen[s] = true
+				merged.Allow = append(merged.Allow, s)
+			}
+		}
+		for s, e := range p.Deny {
+			merged.Deny[s] = e
+		}
+	}
+	add(baselinePolicy)
+	for _, name := range active {
+		if p, ok := Policies[name]; ok {
+			add(p)
+		}
+	}
+	return merged
+}